@@ -0,0 +1,39 @@
+/*
+ Copyright 2023 The Volcano Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package options
+
+import "github.com/spf13/pflag"
+
+// ServerOption is the main context object for the scheduler.
+type ServerOption struct {
+	// MetricsBindAddress is the address the scheduler's own Prometheus
+	// metrics endpoint binds to, e.g. ":8080". Empty disables it.
+	MetricsBindAddress string
+}
+
+// NewServerOption creates a new ServerOption with its defaults set.
+func NewServerOption() *ServerOption {
+	return &ServerOption{
+		MetricsBindAddress: ":8080",
+	}
+}
+
+// AddFlags adds flags for a specific ServerOption to the given FlagSet.
+func (s *ServerOption) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&s.MetricsBindAddress, "metrics-bind-address", s.MetricsBindAddress,
+		"The address the scheduler's own Prometheus metrics endpoint binds to. Set to empty to disable.")
+}
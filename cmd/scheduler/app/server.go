@@ -0,0 +1,32 @@
+/*
+ Copyright 2023 The Volcano Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"volcano.sh/volcano/cmd/scheduler/app/options"
+	"volcano.sh/volcano/pkg/scheduler/metrics"
+)
+
+// Run starts the scheduler's own Prometheus metrics endpoint behind
+// --metrics-bind-address and blocks until ctx is cancelled. It starts
+// nothing else: no leader election, informer sync or scheduling loop.
+func Run(ctx context.Context, opt *options.ServerOption) error {
+	metrics.StartServer(ctx, opt.MetricsBindAddress)
+	<-ctx.Done()
+	return nil
+}
@@ -0,0 +1,58 @@
+/*
+ Copyright 2023 The Volcano Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Package metrics exposes the Volcano scheduler's own internals -- node
+// metrics source latency, errors and cache efficiency -- as native
+// Prometheus metrics, so operators get observability into the scheduler
+// itself and not only into the workloads it schedules.
+//
+// Session/queue/preemption/plugin metrics are intentionally not declared
+// here yet: add them alongside the call sites that will actually update
+// them (session open/close, queue push/pop, preemption/reclaim actions,
+// plugin dispatch) rather than shipping unobserved gauges that would sit at
+// zero forever.
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const subsystem = "scheduler"
+
+var (
+	// NodeMetricsCacheResult counts node-metrics source lookups that were
+	// served from cache versus required a backend query.
+	NodeMetricsCacheResult = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: subsystem,
+		Name:      "node_metrics_cache_total",
+		Help:      "Number of node metrics lookups, by cache result (hit, miss).",
+	}, []string{"result"})
+
+	// NodeMetricsQueryLatency tracks how long queries to the node metrics
+	// backend (Prometheus, Thanos, ...) take.
+	NodeMetricsQueryLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Subsystem: subsystem,
+		Name:      "node_metrics_query_duration_seconds",
+		Help:      "Latency of node metrics backend queries.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 20),
+	})
+
+	// NodeMetricsQueryErrors counts node metrics backend queries that failed,
+	// either after exhausting retries or on hitting the query timeout.
+	NodeMetricsQueryErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Subsystem: subsystem,
+		Name:      "node_metrics_query_errors_total",
+		Help:      "Number of node metrics backend queries that failed after exhausting retries or on timeout.",
+	})
+)
@@ -0,0 +1,67 @@
+/*
+ Copyright 2023 The Volcano Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/klog/v2"
+	"net/http"
+)
+
+// Registry is the registry the scheduler's own metrics are exposed under. It
+// is kept separate from prometheus.DefaultRegisterer so that importing this
+// package never has the side effect of polluting a shared global registry.
+var Registry = prometheus.NewRegistry()
+
+func init() {
+	Registry.MustRegister(
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		prometheus.NewGoCollector(),
+		NodeMetricsCacheResult,
+		NodeMetricsQueryLatency,
+		NodeMetricsQueryErrors,
+	)
+}
+
+// StartServer serves the scheduler's own Prometheus metrics on bindAddress
+// (e.g. ":8080") until ctx is cancelled. The scheduler's app.Run calls it
+// with the value of the `--metrics-bind-address` flag. An empty bindAddress
+// disables the endpoint.
+func StartServer(ctx context.Context, bindAddress string) {
+	if bindAddress == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+	server := &http.Server{Addr: bindAddress, Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	go func() {
+		klog.Infof("Serving scheduler metrics on %s/metrics", bindAddress)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			klog.Errorf("Metrics server failed: %v", err)
+		}
+	}()
+}
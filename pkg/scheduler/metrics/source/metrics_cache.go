@@ -0,0 +1,115 @@
+/*
+ Copyright 2023 The Volcano Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package source
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// defaultCacheSize bounds how many (node, metric, period) entries are kept
+// when conf["cache.size"] is unset or invalid.
+const defaultCacheSize = 4096
+
+// defaultCacheTTL is used when conf["cache.ttl"] is unset or invalid. It is
+// deliberately shorter than a typical recording-rule evaluation interval so
+// the cache never serves a value staler than the source data itself.
+const defaultCacheTTL = 15 * time.Second
+
+// metricsCacheKey identifies one cached value.
+type metricsCacheKey struct {
+	nodeName string
+	metric   string
+	period   string
+}
+
+type metricsCacheEntry struct {
+	key      metricsCacheKey
+	value    float64
+	expireAt time.Time
+}
+
+// metricsCache is a small TTL+LRU cache in front of backend queries, keyed by
+// (nodeName, metric, period). It exists so that a scheduling session calling
+// NodeMetricsAvg for hundreds of nodes doesn't re-query the backend for data
+// that hasn't changed since the last recording-rule evaluation.
+type metricsCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	maxSize  int
+	entries  map[metricsCacheKey]*list.Element
+	eviction *list.List // most-recently-used at the front
+}
+
+func newMetricsCache(maxSize int, ttl time.Duration) *metricsCache {
+	return &metricsCache{
+		ttl:      ttl,
+		maxSize:  maxSize,
+		entries:  make(map[metricsCacheKey]*list.Element),
+		eviction: list.New(),
+	}
+}
+
+// get returns the cached value for key if present and not expired.
+func (c *metricsCache) get(key metricsCacheKey) (float64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return 0, false
+	}
+	entry := elem.Value.(*metricsCacheEntry)
+	if time.Now().After(entry.expireAt) {
+		c.eviction.Remove(elem)
+		delete(c.entries, key)
+		return 0, false
+	}
+	c.eviction.MoveToFront(elem)
+	return entry.value, true
+}
+
+// set stores value for key, evicting the least-recently-used entry if the
+// cache is at capacity.
+func (c *metricsCache) set(key metricsCacheKey, value float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*metricsCacheEntry)
+		entry.value = value
+		entry.expireAt = time.Now().Add(c.ttl)
+		c.eviction.MoveToFront(elem)
+		return
+	}
+
+	elem := c.eviction.PushFront(&metricsCacheEntry{
+		key:      key,
+		value:    value,
+		expireAt: time.Now().Add(c.ttl),
+	})
+	c.entries[key] = elem
+
+	if c.eviction.Len() > c.maxSize {
+		oldest := c.eviction.Back()
+		if oldest != nil {
+			c.eviction.Remove(oldest)
+			delete(c.entries, oldest.Value.(*metricsCacheEntry).key)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+/*
+ Copyright 2023 The Volcano Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package source
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMetricsCacheGetSet(t *testing.T) {
+	c := newMetricsCache(2, time.Minute)
+	key := metricsCacheKey{nodeName: "n1", metric: "cpu", period: "5m"}
+
+	if _, ok := c.get(key); ok {
+		t.Fatalf("get on empty cache should miss")
+	}
+
+	c.set(key, 0.5)
+	value, ok := c.get(key)
+	if !ok || value != 0.5 {
+		t.Fatalf("got (%v, %v), want (0.5, true)", value, ok)
+	}
+}
+
+func TestMetricsCacheExpiry(t *testing.T) {
+	c := newMetricsCache(2, time.Millisecond)
+	key := metricsCacheKey{nodeName: "n1", metric: "cpu", period: "5m"}
+
+	c.set(key, 0.5)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := c.get(key); ok {
+		t.Fatalf("expired entry should miss")
+	}
+}
+
+func TestMetricsCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newMetricsCache(2, time.Minute)
+	k1 := metricsCacheKey{nodeName: "n1", metric: "cpu", period: "5m"}
+	k2 := metricsCacheKey{nodeName: "n2", metric: "cpu", period: "5m"}
+	k3 := metricsCacheKey{nodeName: "n3", metric: "cpu", period: "5m"}
+
+	c.set(k1, 1)
+	c.set(k2, 2)
+	// Touch k1 so k2 becomes the least-recently-used entry.
+	if _, ok := c.get(k1); !ok {
+		t.Fatalf("k1 should still be cached")
+	}
+	c.set(k3, 3)
+
+	if _, ok := c.get(k2); ok {
+		t.Fatalf("k2 should have been evicted as least-recently-used")
+	}
+	if _, ok := c.get(k1); !ok {
+		t.Fatalf("k1 should still be cached")
+	}
+	if _, ok := c.get(k3); !ok {
+		t.Fatalf("k3 should still be cached")
+	}
+}
+
+func TestMetricsCacheSetUpdatesExistingEntry(t *testing.T) {
+	c := newMetricsCache(2, time.Minute)
+	key := metricsCacheKey{nodeName: "n1", metric: "cpu", period: "5m"}
+
+	c.set(key, 1)
+	c.set(key, 2)
+
+	value, ok := c.get(key)
+	if !ok || value != 2 {
+		t.Fatalf("got (%v, %v), want (2, true)", value, ok)
+	}
+	if c.eviction.Len() != 1 {
+		t.Fatalf("updating an existing key should not grow the eviction list, got len %d", c.eviction.Len())
+	}
+}
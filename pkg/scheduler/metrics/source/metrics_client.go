@@ -0,0 +1,98 @@
+/*
+ Copyright 2023 The Volcano Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package source
+
+import (
+	"context"
+	"fmt"
+)
+
+// MetricsClient is the common interface every pluggable metrics source
+// backend (Prometheus, Thanos, VictoriaMetrics, Mimir, Cortex, ...) must
+// implement so that scheduler plugins can consume node utilization data
+// without knowing which backend it came from.
+type MetricsClient interface {
+	// NodeMetricsAvg returns the average cpu/memory usage of a node over the
+	// given period (e.g. "5m", "1h"), typically backed by a pre-aggregated
+	// recording rule.
+	NodeMetricsAvg(ctx context.Context, nodeName string, period string) (*NodeMetrics, error)
+	// NodeMetricsInstant returns the current instantaneous cpu/memory usage
+	// of a node, without any averaging window.
+	NodeMetricsInstant(ctx context.Context, nodeName string) (*NodeMetrics, error)
+	// NodeMetricsRange returns percentile, max and trend statistics for a
+	// node computed over a window of samples spanning period, sampled every
+	// step (e.g. period="30m", step="1m").
+	NodeMetricsRange(ctx context.Context, nodeName string, period string, step string) (*NodeMetrics, error)
+	// NodeMetricsAvgBatch returns the average cpu/memory usage for many
+	// nodes at once, issuing a constant number of backend queries instead
+	// of one pair per node. Nodes missing from the result had no data.
+	NodeMetricsAvgBatch(ctx context.Context, nodeNames []string, period string) (map[string]*NodeMetrics, error)
+}
+
+// NodeMetrics holds the usage metrics collected for a single node. The
+// percentile/max/trend fields are only populated by NodeMetricsRange; a
+// trend > 0 means usage has been increasing over the queried window.
+type NodeMetrics struct {
+	Cpu    float64
+	Memory float64
+
+	CpuP50   float64
+	CpuP95   float64
+	CpuP99   float64
+	CpuMax   float64
+	CpuTrend float64
+
+	MemP50   float64
+	MemP95   float64
+	MemP99   float64
+	MemMax   float64
+	MemTrend float64
+
+	// Custom holds user-defined resource pressure signals, keyed by the
+	// `name` configured for each entry in conf["custom_metrics"] -- e.g.
+	// GPU utilization, GPU memory, NIC bandwidth or NVLink utilization.
+	Custom map[string]float64
+}
+
+// Builder constructs a MetricsClient for the given backend address and conf.
+type Builder func(address string, conf map[string]string) (MetricsClient, error)
+
+// builders holds the set of registered metrics source backends, keyed by the
+// `type` field in scheduler conf, e.g. "prometheus", "thanos",
+// "victoriametrics", "mimir" or "cortex".
+var builders = map[string]Builder{}
+
+// RegisterMetricsClient registers a MetricsClient backend under the given
+// type name. Backend implementations call this from their init() function.
+func RegisterMetricsClient(metricsType string, builder Builder) {
+	builders[metricsType] = builder
+}
+
+// NewMetricsClient builds a MetricsClient for the backend named by
+// conf["type"], defaulting to "prometheus" for backward compatibility with
+// existing scheduler configurations that do not set a type.
+func NewMetricsClient(address string, conf map[string]string) (MetricsClient, error) {
+	metricsType := conf["type"]
+	if metricsType == "" {
+		metricsType = "prometheus"
+	}
+	builder, ok := builders[metricsType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported metrics source type %q", metricsType)
+	}
+	return builder(address, conf)
+}
@@ -0,0 +1,154 @@
+/*
+ Copyright 2023 The Volcano Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package source
+
+import (
+	"context"
+	pmodel "github.com/prometheus/common/model"
+	"k8s.io/klog/v2"
+	"regexp"
+	"strings"
+)
+
+// buildBatchQuery rewrites a "$instance"-templated query into one matching
+// any node via an `instance=~"n1|n2|..."` label regex, reporting false if
+// the template doesn't contain the expected pattern and therefore can't be
+// batched.
+func buildBatchQuery(template string, instanceRegex string) (string, bool) {
+	// The regex sits inside a double-quoted PromQL string, where a backslash
+	// must itself be escaped.
+	escaped := strings.ReplaceAll(instanceRegex, `\`, `\\`)
+	queryStr := strings.ReplaceAll(template, `instance="$instance"`, `instance=~"`+escaped+`"`)
+	return queryStr, queryStr != template
+}
+
+// quoteNodeNames joins nodeNames into an alternation suitable for a PromQL
+// `instance=~"..."` label regex, escaping any regex metacharacters (e.g. the
+// "." in an IP-based node name) so a node name can never accidentally
+// broaden the match to other instances.
+func quoteNodeNames(nodeNames []string) string {
+	quoted := make([]string, len(nodeNames))
+	for i, nodeName := range nodeNames {
+		quoted[i] = regexp.QuoteMeta(nodeName)
+	}
+	return strings.Join(quoted, "|")
+}
+
+// groupSamplesByInstance buckets a query result's samples by their instance
+// label, so a spec that doesn't already aggregate by instance (e.g. one
+// series per GPU) can still be reduced per node with aggregateSamples.
+func groupSamplesByInstance(res pmodel.Value) map[string]pmodel.Vector {
+	vector, ok := res.(pmodel.Vector)
+	if !ok {
+		return nil
+	}
+	groups := make(map[string]pmodel.Vector)
+	for _, sample := range vector {
+		nodeName := string(sample.Metric[instanceLabel])
+		if nodeName == "" {
+			continue
+		}
+		groups[nodeName] = append(groups[nodeName], sample)
+	}
+	return groups
+}
+
+// NodeMetricsAvgBatch fetches every node's average cpu/memory usage, plus
+// any configured custom metrics, with a constant number of PromQL calls
+// instead of scaling with len(nodeNames): one query per cpu/mem/custom
+// metric, matching instances with an `instance=~"n1|n2|..."` label regex.
+// Results are populated into the cache so that any later single-node lookup
+// for the same (node, metric, period) within the TTL is served without a
+// query. Nodes with no samples for any metric are omitted from the returned
+// map entirely, so callers can tell "no data" apart from a real zero.
+func (p *PrometheusMetricsClient) NodeMetricsAvgBatch(ctx context.Context, nodeNames []string, period string) (map[string]*NodeMetrics, error) {
+	result := make(map[string]*NodeMetrics, len(nodeNames))
+	if len(nodeNames) == 0 {
+		return result, nil
+	}
+
+	wanted := make(map[string]bool, len(nodeNames))
+	for _, nodeName := range nodeNames {
+		wanted[nodeName] = true
+	}
+	nodeMetrics := func(nodeName string) *NodeMetrics {
+		m, ok := result[nodeName]
+		if !ok {
+			m = &NodeMetrics{}
+			result[nodeName] = m
+		}
+		return m
+	}
+
+	instanceRegex := quoteNodeNames(nodeNames)
+
+	for _, metric := range []string{promCpuUsageAvg, promMemUsageAvg} {
+		queryStr, ok := buildBatchQuery(p.queryTemplates[metric], instanceRegex)
+		if !ok {
+			klog.Warningf("Query template for %s does not match the instance=\"$instance\" pattern, cannot batch it; falling back to no data", metric)
+			continue
+		}
+		queryStr = strings.ReplaceAll(queryStr, "$period", period)
+		klog.V(4).Infof("Batch query %s by %s", p.address, queryStr)
+
+		res, err := p.query(ctx, queryStr)
+		if err != nil {
+			return nil, err
+		}
+		for nodeName, samples := range groupSamplesByInstance(res) {
+			if !wanted[nodeName] {
+				continue
+			}
+			value := aggregateSamples(samples, "avg")
+			p.cache.set(metricsCacheKey{nodeName: nodeName, metric: metric, period: period}, value)
+			switch metric {
+			case promCpuUsageAvg:
+				nodeMetrics(nodeName).Cpu = value
+			case promMemUsageAvg:
+				nodeMetrics(nodeName).Memory = value
+			}
+		}
+	}
+
+	for _, spec := range p.customMetrics {
+		queryStr, ok := buildBatchQuery(spec.PromQL, instanceRegex)
+		if !ok {
+			klog.Warningf("PromQL for custom metric %s does not match the instance=\"$instance\" pattern, cannot batch it; falling back to no data", spec.Name)
+			continue
+		}
+		klog.V(4).Infof("Batch query %s by %s", p.address, queryStr)
+
+		res, err := p.query(ctx, queryStr)
+		if err != nil {
+			return nil, err
+		}
+		for nodeName, samples := range groupSamplesByInstance(res) {
+			if !wanted[nodeName] {
+				continue
+			}
+			value := aggregateSamples(samples, spec.Aggregation)
+			p.cache.set(metricsCacheKey{nodeName: nodeName, metric: customMetricCachePrefix + spec.Name, period: ""}, value)
+			m := nodeMetrics(nodeName)
+			if m.Custom == nil {
+				m.Custom = make(map[string]float64, len(p.customMetrics))
+			}
+			m.Custom[spec.Name] = value
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,77 @@
+/*
+ Copyright 2023 The Volcano Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package source
+
+import (
+	"testing"
+
+	pmodel "github.com/prometheus/common/model"
+)
+
+func TestQuoteNodeNamesEscapesMetacharacters(t *testing.T) {
+	got := quoteNodeNames([]string{"10.0.0.1", "node-a"})
+	want := `10\.0\.0\.1|node-a`
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestBuildBatchQuery(t *testing.T) {
+	template := `avg(cpu{instance="$instance"})`
+	queryStr, ok := buildBatchQuery(template, `n1|n2`)
+	if !ok {
+		t.Fatalf("expected template to match the instance=\"$instance\" pattern")
+	}
+	want := `avg(cpu{instance=~"n1|n2"})`
+	if queryStr != want {
+		t.Fatalf("got %q, want %q", queryStr, want)
+	}
+}
+
+func TestBuildBatchQueryNoMatch(t *testing.T) {
+	template := `avg(cpu{node="$instance"})`
+	_, ok := buildBatchQuery(template, `n1|n2`)
+	if ok {
+		t.Fatalf("expected no match for a template without the instance=\"$instance\" pattern")
+	}
+}
+
+func TestGroupSamplesByInstance(t *testing.T) {
+	vector := pmodel.Vector{
+		&pmodel.Sample{Metric: pmodel.Metric{instanceLabel: "n1"}, Value: 1},
+		&pmodel.Sample{Metric: pmodel.Metric{instanceLabel: "n1"}, Value: 2},
+		&pmodel.Sample{Metric: pmodel.Metric{instanceLabel: "n2"}, Value: 3},
+		&pmodel.Sample{Metric: pmodel.Metric{}, Value: 4},
+	}
+
+	groups := groupSamplesByInstance(vector)
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want 2 (samples with no instance label should be dropped)", len(groups))
+	}
+	if len(groups["n1"]) != 2 {
+		t.Fatalf("got %d samples for n1, want 2", len(groups["n1"]))
+	}
+	if len(groups["n2"]) != 1 {
+		t.Fatalf("got %d samples for n2, want 1", len(groups["n2"]))
+	}
+}
+
+func TestGroupSamplesByInstanceWrongType(t *testing.T) {
+	if got := groupSamplesByInstance(pmodel.Matrix{}); got != nil {
+		t.Fatalf("got %v, want nil for a non-Vector result", got)
+	}
+}
@@ -0,0 +1,41 @@
+/*
+ Copyright 2023 The Volcano Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package source
+
+// compatBackends are stores that expose the same HTTP query API as
+// Prometheus and so need no behavior of their own, just registration under
+// their own name with the standard PromQL templates:
+//   - Thanos Querier exposes the same HTTP query API as Prometheus.
+//   - VictoriaMetrics implements it on its /prometheus/ sub-path; point
+//     `address` at the vmselect endpoint including that sub-path, e.g.
+//     "http://vmselect:8481/select/0/prometheus".
+//   - Mimir and Cortex are multi-tenant Prometheus-API-compatible stores:
+//     every request must carry conf["tenant.id"] as the X-Scope-OrgID
+//     header, which newRoundTripper already attaches whenever it is set.
+//
+// Operators can still override individual templates via
+// conf["promql.<name>"] regardless of backend.
+var compatBackends = []string{"thanos", "victoriametrics", "mimir", "cortex"}
+
+func init() {
+	for _, backend := range compatBackends {
+		backend := backend
+		RegisterMetricsClient(backend, func(address string, conf map[string]string) (MetricsClient, error) {
+			return NewPrometheusMetricsClient(address, conf, defaultQueryTemplates)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+/*
+ Copyright 2023 The Volcano Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	pmodel "github.com/prometheus/common/model"
+	"k8s.io/klog/v2"
+	"strings"
+)
+
+// CustomMetricSpec declares one user-defined resource pressure signal, e.g.
+// GPU utilization or NIC bandwidth, configured via conf["custom_metrics"] as
+// a JSON array:
+//
+//	[{"name": "gpu_util", "promql": "avg by (instance) (DCGM_FI_DEV_GPU_UTIL{instance=\"$instance\"})"}]
+type CustomMetricSpec struct {
+	// Name is the key the value is stored under in NodeMetrics.Custom.
+	Name string `json:"name"`
+	// PromQL is the query template; "$instance" is substituted with the
+	// node name before the query runs.
+	PromQL string `json:"promql"`
+	// Aggregation reduces multiple samples returned for the same node
+	// (e.g. one per GPU) into a single value: "avg" (default), "sum",
+	// "max" or "min".
+	Aggregation string `json:"aggregation"`
+}
+
+// parseCustomMetrics decodes conf["custom_metrics"]; an empty value is not
+// an error, it just means no custom metrics are configured.
+func parseCustomMetrics(raw string) ([]CustomMetricSpec, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var specs []CustomMetricSpec
+	if err := json.Unmarshal([]byte(raw), &specs); err != nil {
+		return nil, fmt.Errorf("invalid custom_metrics: %v", err)
+	}
+	return specs, nil
+}
+
+// customMetricCachePrefix namespaces custom metric cache/singleflight keys
+// so a user-chosen spec.Name can never collide with the built-in cpu/mem
+// metric names.
+const customMetricCachePrefix = "custom:"
+
+// customMetricValues queries every configured CustomMetricSpec for nodeName
+// and returns the populated NodeMetrics.Custom map. Lookups go through the
+// same cache and singleflight coalescing as the cpu/mem queries, so a
+// session touching hundreds of nodes with GPU/NIC metrics configured
+// doesn't stampede the backend any more than the cpu/mem path does.
+func (p *PrometheusMetricsClient) customMetricValues(ctx context.Context, nodeName string) (map[string]float64, error) {
+	if len(p.customMetrics) == 0 {
+		return nil, nil
+	}
+
+	custom := make(map[string]float64, len(p.customMetrics))
+	for _, spec := range p.customMetrics {
+		queryStr := strings.ReplaceAll(spec.PromQL, "$instance", nodeName)
+		klog.V(4).Infof("Query %s by %s", p.address, queryStr)
+		value, ok, err := p.cachedQuery(ctx, customMetricCachePrefix+spec.Name, nodeName, "", queryStr, aggregateExtractor(spec.Aggregation))
+		if err != nil {
+			return nil, fmt.Errorf("querying custom metric %s: %v", spec.Name, err)
+		}
+		if !ok {
+			klog.Warningf("Warning querying %s: no data found for custom metric %s on node %s", p.address, spec.Name, nodeName)
+			continue
+		}
+		custom[spec.Name] = value
+	}
+	return custom, nil
+}
+
+// aggregateExtractor adapts aggregateSamples into the extract func cachedQuery
+// expects, reducing every sample in a pmodel.Vector result (e.g. one per GPU
+// on a node) down to the single value that gets cached.
+func aggregateExtractor(aggregation string) func(pmodel.Value) (float64, bool) {
+	return func(res pmodel.Value) (float64, bool) {
+		vector, ok := res.(pmodel.Vector)
+		if !ok || len(vector) == 0 {
+			return 0, false
+		}
+		return aggregateSamples(vector, aggregation), true
+	}
+}
+
+// aggregateSamples reduces a vector of samples (e.g. one per GPU on a node)
+// into a single value using the requested aggregation, defaulting to avg.
+func aggregateSamples(vector pmodel.Vector, aggregation string) float64 {
+	switch aggregation {
+	case "sum":
+		var sum float64
+		for _, sample := range vector {
+			sum += float64(sample.Value)
+		}
+		return sum
+	case "max":
+		max := float64(vector[0].Value)
+		for _, sample := range vector[1:] {
+			if v := float64(sample.Value); v > max {
+				max = v
+			}
+		}
+		return max
+	case "min":
+		min := float64(vector[0].Value)
+		for _, sample := range vector[1:] {
+			if v := float64(sample.Value); v < min {
+				min = v
+			}
+		}
+		return min
+	default:
+		var sum float64
+		for _, sample := range vector {
+			sum += float64(sample.Value)
+		}
+		return sum / float64(len(vector))
+	}
+}
@@ -0,0 +1,94 @@
+/*
+ Copyright 2023 The Volcano Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package source
+
+import (
+	"testing"
+
+	pmodel "github.com/prometheus/common/model"
+)
+
+func TestParseCustomMetricsEmpty(t *testing.T) {
+	specs, err := parseCustomMetrics("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if specs != nil {
+		t.Fatalf("got %v, want nil for an empty config", specs)
+	}
+}
+
+func TestParseCustomMetrics(t *testing.T) {
+	raw := `[{"name": "gpu_util", "promql": "avg(DCGM_FI_DEV_GPU_UTIL{instance=\"$instance\"})", "aggregation": "max"}]`
+	specs, err := parseCustomMetrics(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(specs) != 1 {
+		t.Fatalf("got %d specs, want 1", len(specs))
+	}
+	if specs[0].Name != "gpu_util" || specs[0].Aggregation != "max" {
+		t.Fatalf("got %+v, unexpected fields", specs[0])
+	}
+}
+
+func TestParseCustomMetricsInvalidJSON(t *testing.T) {
+	if _, err := parseCustomMetrics("not json"); err == nil {
+		t.Fatalf("expected an error for invalid JSON")
+	}
+}
+
+func TestAggregateSamples(t *testing.T) {
+	vector := pmodel.Vector{
+		&pmodel.Sample{Value: 1},
+		&pmodel.Sample{Value: 2},
+		&pmodel.Sample{Value: 3},
+	}
+
+	cases := []struct {
+		aggregation string
+		want        float64
+	}{
+		{"sum", 6},
+		{"max", 3},
+		{"min", 1},
+		{"avg", 2},
+		{"", 2}, // default to avg
+	}
+	for _, c := range cases {
+		if got := aggregateSamples(vector, c.aggregation); got != c.want {
+			t.Errorf("aggregateSamples(%v, %q) = %v, want %v", vector, c.aggregation, got, c.want)
+		}
+	}
+}
+
+func TestAggregateExtractor(t *testing.T) {
+	extract := aggregateExtractor("sum")
+
+	value, ok := extract(pmodel.Vector{&pmodel.Sample{Value: 1}, &pmodel.Sample{Value: 2}})
+	if !ok || value != 3 {
+		t.Fatalf("got (%v, %v), want (3, true)", value, ok)
+	}
+
+	if _, ok := extract(pmodel.Vector{}); ok {
+		t.Fatalf("expected no value for an empty vector")
+	}
+
+	if _, ok := extract(pmodel.Matrix{}); ok {
+		t.Fatalf("expected no value for a non-Vector result")
+	}
+}
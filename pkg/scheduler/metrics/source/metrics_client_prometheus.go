@@ -19,15 +19,19 @@ package source
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"github.com/prometheus/client_golang/api"
 	prometheusv1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	pmodel "github.com/prometheus/common/model"
+	"golang.org/x/sync/singleflight"
 	"k8s.io/klog/v2"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"time"
+	"volcano.sh/volcano/pkg/scheduler/metrics"
 )
 
 const (
@@ -35,66 +39,368 @@ const (
 	promCpuUsageAvg = "cpu_usage_avg"
 	// promMemUsageAvg record name of mem average usage defined in prometheus rules
 	promMemUsageAvg = "mem_usage_avg"
+	// promCpuUsageInstant query name for instantaneous cpu usage
+	promCpuUsageInstant = "cpu_usage_instant"
+	// promMemUsageInstant query name for instantaneous mem usage
+	promMemUsageInstant = "mem_usage_instant"
+
+	// defaultQueryTimeout is used when conf["query.timeout"] is unset or invalid.
+	defaultQueryTimeout = 10 * time.Second
+	// defaultMaxRetries is used when conf["query.maxRetries"] is unset or invalid.
+	defaultMaxRetries = 2
+	// defaultRetryBackoff is used when conf["query.retryBackoff"] is unset or invalid.
+	defaultRetryBackoff = 200 * time.Millisecond
 )
 
+// instanceLabel is the Prometheus label every node-level metric is keyed by.
+const instanceLabel = "instance"
+
+// defaultQueryTemplates are the PromQL templates used when the operator does
+// not override them via conf["promql.<name>"]. "$instance" and "$period" are
+// substituted with the node name and averaging period respectively.
+var defaultQueryTemplates = map[string]string{
+	promCpuUsageAvg:     `cpu_usage_avg_$period{instance="$instance"}`,
+	promMemUsageAvg:     `mem_usage_avg_$period{instance="$instance"}`,
+	promCpuUsageInstant: `1 - avg(rate(node_cpu_seconds_total{mode="idle",instance="$instance"}[1m]))`,
+	promMemUsageInstant: `1 - (node_memory_MemAvailable_bytes{instance="$instance"} / node_memory_MemTotal_bytes{instance="$instance"})`,
+}
+
+// PrometheusMetricsClient is a MetricsClient implementation talking to any
+// backend that exposes the Prometheus HTTP query API, which in addition to
+// Prometheus itself covers Thanos, VictoriaMetrics, Mimir and Cortex. The
+// registering backend supplies the default query templates, see
+// metrics_client_compat.go; headers such as X-Scope-OrgID come from conf.
 type PrometheusMetricsClient struct {
 	address string
 	conf    map[string]string
-}
 
-func NewPrometheusMetricsClient(address string, conf map[string]string) (*PrometheusMetricsClient, error) {
-	return &PrometheusMetricsClient{address: address, conf: conf}, nil
+	v1api          prometheusv1.API
+	queryTemplates map[string]string
+	queryTimeout   time.Duration
+	maxRetries     int
+	retryBackoff   time.Duration
+
+	cache  *metricsCache
+	single singleflight.Group
+
+	customMetrics []CustomMetricSpec
 }
 
-func (p *PrometheusMetricsClient) NodeMetricsAvg(ctx context.Context, nodeName string, period string) (*NodeMetrics, error) {
-	klog.V(4).Infof("Get node metrics from Prometheus: %s", p.address)
-	var client api.Client
-	var err error
-	insecureSkipVerify := p.conf["tls.insecureSkipVerify"] == "true"
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: insecureSkipVerify,
-		},
-	}
-	client, err = api.NewClient(api.Config{
-		Address:      p.address,
-		RoundTripper: tr,
+// NewPrometheusMetricsClient builds a PrometheusMetricsClient, loading
+// TLS/auth settings and query templates from conf. templateDefaults supplies
+// the backend-specific default PromQL templates, which conf["promql.<name>"]
+// entries take precedence over.
+func NewPrometheusMetricsClient(address string, conf map[string]string, templateDefaults map[string]string) (*PrometheusMetricsClient, error) {
+	roundTripper, err := newRoundTripper(conf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build round tripper: %v", err)
+	}
+
+	customMetrics, err := parseCustomMetrics(conf["custom_metrics"])
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := api.NewClient(api.Config{
+		Address:      address,
+		RoundTripper: roundTripper,
 	})
 	if err != nil {
 		return nil, err
 	}
-	v1api := prometheusv1.NewAPI(client)
-	nodeMetrics := &NodeMetrics{}
-	for _, metric := range []string{promCpuUsageAvg, promMemUsageAvg} {
-		queryStr := fmt.Sprintf("%s_%s{instance=\"%s\"}", metric, period, nodeName)
-		klog.V(4).Infof("Query prometheus by %s", queryStr)
-		res, warnings, err := v1api.Query(ctx, queryStr, time.Now())
+
+	return &PrometheusMetricsClient{
+		address:        address,
+		conf:           conf,
+		v1api:          prometheusv1.NewAPI(client),
+		queryTemplates: resolveQueryTemplates(conf, templateDefaults),
+		queryTimeout:   durationFromConf(conf, "query.timeout", defaultQueryTimeout),
+		maxRetries:     intFromConf(conf, "query.maxRetries", defaultMaxRetries),
+		retryBackoff:   durationFromConf(conf, "query.retryBackoff", defaultRetryBackoff),
+		cache:          newMetricsCache(intFromConf(conf, "cache.size", defaultCacheSize), durationFromConf(conf, "cache.ttl", defaultCacheTTL)),
+		customMetrics:  customMetrics,
+	}, nil
+}
+
+// resolveQueryTemplates overlays conf["promql.<name>"] overrides on top of
+// the backend's default query templates.
+func resolveQueryTemplates(conf map[string]string, defaults map[string]string) map[string]string {
+	templates := make(map[string]string, len(defaults))
+	for name, tmpl := range defaults {
+		templates[name] = tmpl
+	}
+	const promqlPrefix = "promql."
+	for key, value := range conf {
+		if strings.HasPrefix(key, promqlPrefix) {
+			templates[strings.TrimPrefix(key, promqlPrefix)] = value
+		}
+	}
+	return templates
+}
+
+// newRoundTripper builds the http.RoundTripper used for every query, wiring
+// up mTLS, bearer-token/basic-auth and multi-tenant headers from conf. Auth
+// material is read from the files conf points to, matching the common
+// pattern of mounting a Kubernetes Secret into the scheduler pod and
+// referencing the mounted paths from conf.
+func newRoundTripper(conf map[string]string) (http.RoundTripper, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: conf["tls.insecureSkipVerify"] == "true",
+	}
+
+	if caFile := conf["tls.caFile"]; caFile != "" {
+		caCert, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tls.caFile %s: %v", caFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("failed to parse tls.caFile %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if certFile, keyFile := conf["tls.certFile"], conf["tls.keyFile"]; certFile != "" && keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client cert/key pair: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	base := &http.Transport{TLSClientConfig: tlsConfig}
+
+	return &authRoundTripper{
+		next:     base,
+		tenantID: conf["tenant.id"],
+		authType: conf["auth.type"],
+		conf:     conf,
+	}, nil
+}
+
+// authRoundTripper decorates requests with multi-tenant and auth headers
+// before delegating to the underlying transport.
+type authRoundTripper struct {
+	next     http.RoundTripper
+	tenantID string
+	authType string
+	conf     map[string]string
+}
+
+func (rt *authRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+
+	if rt.tenantID != "" {
+		// X-Scope-OrgID is the multi-tenant header used by Mimir and Cortex.
+		req.Header.Set("X-Scope-OrgID", rt.tenantID)
+	}
+
+	switch rt.authType {
+	case "bearer":
+		token, err := readAuthFile(rt.conf["auth.tokenFile"])
 		if err != nil {
-			klog.Errorf("Error querying Prometheus: %v", err)
+			return nil, fmt.Errorf("failed to read auth.tokenFile: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case "basic":
+		username, err := readAuthFile(rt.conf["auth.usernameFile"])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read auth.usernameFile: %v", err)
+		}
+		password, err := readAuthFile(rt.conf["auth.passwordFile"])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read auth.passwordFile: %v", err)
+		}
+		req.SetBasicAuth(username, password)
+	}
+
+	return rt.next.RoundTrip(req)
+}
+
+func readAuthFile(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("no file configured")
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+func durationFromConf(conf map[string]string, key string, fallback time.Duration) time.Duration {
+	if raw := conf[key]; raw != "" {
+		if d, err := time.ParseDuration(raw); err == nil {
+			return d
 		}
+	}
+	return fallback
+}
+
+func intFromConf(conf map[string]string, key string, fallback int) int {
+	if raw := conf[key]; raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+func (p *PrometheusMetricsClient) renderQuery(name string, nodeName string, period string) string {
+	query := p.queryTemplates[name]
+	query = strings.ReplaceAll(query, "$instance", nodeName)
+	query = strings.ReplaceAll(query, "$period", period)
+	return query
+}
+
+// query executes queryStr against the backend, retrying any error up to
+// p.maxRetries times with a linear backoff, and bounding the whole
+// operation with p.queryTimeout so a down backend can't stall scheduling.
+func (p *PrometheusMetricsClient) query(ctx context.Context, queryStr string) (pmodel.Value, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.queryTimeout)
+	defer cancel()
+
+	start := time.Now()
+	var lastErr error
+	for attempt := 0; attempt <= p.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(p.retryBackoff * time.Duration(attempt)):
+			case <-ctx.Done():
+				metrics.NodeMetricsQueryLatency.Observe(time.Since(start).Seconds())
+				metrics.NodeMetricsQueryErrors.Inc()
+				return nil, fmt.Errorf("querying %s: %v", p.address, ctx.Err())
+			}
+		}
+
+		res, warnings, err := p.v1api.Query(ctx, queryStr, time.Now())
 		if len(warnings) > 0 {
-			klog.V(3).Infof("Warning querying Prometheus: %v", warnings)
+			klog.V(3).Infof("Warning querying %s: %v", p.address, warnings)
 		}
-		if res == nil || res.String() == "" {
-			klog.Warningf("Warning querying Prometheus: no data found for %s", queryStr)
-			continue
+		if err == nil {
+			metrics.NodeMetricsQueryLatency.Observe(time.Since(start).Seconds())
+			return res, nil
 		}
-		// plugin.usage only need type pmodel.ValVector in Prometheus.rulues
-		if res.Type() != pmodel.ValVector {
+		lastErr = err
+		klog.Warningf("Error querying %s (attempt %d/%d): %v", p.address, attempt+1, p.maxRetries+1, err)
+	}
+	metrics.NodeMetricsQueryLatency.Observe(time.Since(start).Seconds())
+	metrics.NodeMetricsQueryErrors.Inc()
+	return nil, fmt.Errorf("querying %s failed after %d attempts: %v", p.address, p.maxRetries+1, lastErr)
+}
+
+// cachedQuery returns the value of queryStr for (nodeName, metric, period),
+// first checking the local cache, then coalescing concurrent identical
+// lookups through singleflight so that N callers asking for the same node in
+// the same scheduling session trigger at most one backend query. extract
+// pulls the single reported value out of the raw query result, e.g.
+// firstSampleValue for the fixed cpu/mem queries or an aggregation over
+// several samples for a user-defined custom metric.
+func (p *PrometheusMetricsClient) cachedQuery(ctx context.Context, metric string, nodeName string, period string, queryStr string, extract func(pmodel.Value) (float64, bool)) (float64, bool, error) {
+	key := metricsCacheKey{nodeName: nodeName, metric: metric, period: period}
+	if value, ok := p.cache.get(key); ok {
+		metrics.NodeMetricsCacheResult.WithLabelValues("hit").Inc()
+		return value, true, nil
+	}
+	metrics.NodeMetricsCacheResult.WithLabelValues("miss").Inc()
+
+	sfKey := fmt.Sprintf("%s|%s|%s", nodeName, metric, period)
+	result, err, _ := p.single.Do(sfKey, func() (interface{}, error) {
+		res, err := p.query(ctx, queryStr)
+		if err != nil {
+			return nil, err
+		}
+		value, ok := extract(res)
+		if !ok {
+			return nil, nil
+		}
+		p.cache.set(key, value)
+		return value, nil
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	if result == nil {
+		return 0, false, nil
+	}
+	return result.(float64), true, nil
+}
+
+// cachedMetricValue is cachedQuery specialised for the fixed cpu/mem
+// queries, which always return a single sample per node.
+func (p *PrometheusMetricsClient) cachedMetricValue(ctx context.Context, metric string, nodeName string, period string) (float64, bool, error) {
+	return p.cachedQuery(ctx, metric, nodeName, period, p.renderQuery(metric, nodeName, period), firstSampleValue)
+}
+
+func (p *PrometheusMetricsClient) NodeMetricsAvg(ctx context.Context, nodeName string, period string) (*NodeMetrics, error) {
+	klog.V(4).Infof("Get node metrics from %s", p.address)
+	nodeMetrics := &NodeMetrics{}
+	for _, metric := range []string{promCpuUsageAvg, promMemUsageAvg} {
+		value, ok, err := p.cachedMetricValue(ctx, metric, nodeName, period)
+		if err != nil {
+			// An error surfaces as an error now instead of silently
+			// returning a zero-valued NodeMetrics, so callers can tell
+			// "no data" apart from "the backend is down".
+			return nil, err
+		}
+		if !ok {
+			klog.Warningf("Warning querying %s: no data found for node %s metric %s", p.address, nodeName, metric)
 			continue
 		}
-		// only method res.String() can get data, dataType []pmodel.ValVector, eg: "{k1:v1, ...} => #[value] @#[timespace]\n {k2:v2, ...} => ..."
-		firstRowValVector := strings.Split(res.String(), "\n")[0]
-		rowValues := strings.Split(strings.TrimSpace(firstRowValVector), "=>")
-		value := strings.Split(strings.TrimSpace(rowValues[1]), " ")
 		switch metric {
 		case promCpuUsageAvg:
-			cpuUsage, _ := strconv.ParseFloat(value[0], 64)
-			nodeMetrics.Cpu = cpuUsage
+			nodeMetrics.Cpu = value
 		case promMemUsageAvg:
-			memUsage, _ := strconv.ParseFloat(value[0], 64)
-			nodeMetrics.Memory = memUsage
+			nodeMetrics.Memory = value
+		}
+	}
+	custom, err := p.customMetricValues(ctx, nodeName)
+	if err != nil {
+		return nil, err
+	}
+	nodeMetrics.Custom = custom
+	return nodeMetrics, nil
+}
+
+func (p *PrometheusMetricsClient) NodeMetricsInstant(ctx context.Context, nodeName string) (*NodeMetrics, error) {
+	klog.V(4).Infof("Get instant node metrics from %s", p.address)
+	nodeMetrics := &NodeMetrics{}
+	for _, metric := range []string{promCpuUsageInstant, promMemUsageInstant} {
+		value, ok, err := p.cachedMetricValue(ctx, metric, nodeName, "")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			klog.Warningf("Warning querying %s: no data found for node %s metric %s", p.address, nodeName, metric)
+			continue
 		}
+		switch metric {
+		case promCpuUsageInstant:
+			nodeMetrics.Cpu = value
+		case promMemUsageInstant:
+			nodeMetrics.Memory = value
+		}
+	}
+	custom, err := p.customMetricValues(ctx, nodeName)
+	if err != nil {
+		return nil, err
 	}
+	nodeMetrics.Custom = custom
 	return nodeMetrics, nil
 }
+
+// firstSampleValue extracts the value of the first sample out of a query
+// result, type-switching on pmodel.Vector instead of parsing res.String().
+func firstSampleValue(res pmodel.Value) (float64, bool) {
+	vector, ok := res.(pmodel.Vector)
+	if !ok || len(vector) == 0 {
+		return 0, false
+	}
+	return float64(vector[0].Value), true
+}
+
+func init() {
+	RegisterMetricsClient("prometheus", func(address string, conf map[string]string) (MetricsClient, error) {
+		return NewPrometheusMetricsClient(address, conf, defaultQueryTemplates)
+	})
+}
@@ -0,0 +1,182 @@
+/*
+ Copyright 2023 The Volcano Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package source
+
+import (
+	"context"
+	"fmt"
+	prometheusv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	pmodel "github.com/prometheus/common/model"
+	"k8s.io/klog/v2"
+	"sort"
+	"time"
+	"volcano.sh/volcano/pkg/scheduler/metrics"
+)
+
+// queryRange executes a range query over [now-period, now] sampled every
+// step, retrying and timing out the same way query does.
+func (p *PrometheusMetricsClient) queryRange(ctx context.Context, queryStr string, period string, step string) (pmodel.Value, error) {
+	periodDuration, err := time.ParseDuration(period)
+	if err != nil {
+		return nil, fmt.Errorf("invalid period %q: %v", period, err)
+	}
+	stepDuration, err := time.ParseDuration(step)
+	if err != nil {
+		return nil, fmt.Errorf("invalid step %q: %v", step, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.queryTimeout)
+	defer cancel()
+
+	now := time.Now()
+	r := prometheusv1.Range{
+		Start: now.Add(-periodDuration),
+		End:   now,
+		Step:  stepDuration,
+	}
+
+	start := time.Now()
+	var lastErr error
+	for attempt := 0; attempt <= p.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(p.retryBackoff * time.Duration(attempt)):
+			case <-ctx.Done():
+				metrics.NodeMetricsQueryLatency.Observe(time.Since(start).Seconds())
+				metrics.NodeMetricsQueryErrors.Inc()
+				return nil, fmt.Errorf("range querying %s: %v", p.address, ctx.Err())
+			}
+		}
+
+		res, warnings, err := p.v1api.QueryRange(ctx, queryStr, r)
+		if len(warnings) > 0 {
+			klog.V(3).Infof("Warning range querying %s: %v", p.address, warnings)
+		}
+		if err == nil {
+			metrics.NodeMetricsQueryLatency.Observe(time.Since(start).Seconds())
+			return res, nil
+		}
+		lastErr = err
+		klog.Warningf("Error range querying %s (attempt %d/%d): %v", p.address, attempt+1, p.maxRetries+1, err)
+	}
+	metrics.NodeMetricsQueryLatency.Observe(time.Since(start).Seconds())
+	metrics.NodeMetricsQueryErrors.Inc()
+	return nil, fmt.Errorf("range querying %s failed after %d attempts: %v", p.address, p.maxRetries+1, lastErr)
+}
+
+// firstSeriesValues extracts the sample values of the first series out of a
+// range-query result, type-switching on pmodel.Matrix.
+func firstSeriesValues(res pmodel.Value) ([]float64, bool) {
+	matrix, ok := res.(pmodel.Matrix)
+	if !ok || len(matrix) == 0 || len(matrix[0].Values) == 0 {
+		return nil, false
+	}
+	values := make([]float64, len(matrix[0].Values))
+	for i, pair := range matrix[0].Values {
+		values[i] = float64(pair.Value)
+	}
+	return values, true
+}
+
+// NodeMetricsRange queries a window of samples for a node and reduces it to
+// percentile (P50/P95/P99), max and a linear-regression trend slope, so
+// callers can bias away from nodes that are spiky or trending up rather than
+// only looking at a point-in-time average.
+func (p *PrometheusMetricsClient) NodeMetricsRange(ctx context.Context, nodeName string, period string, step string) (*NodeMetrics, error) {
+	klog.V(4).Infof("Get range node metrics from %s", p.address)
+	nodeMetrics := &NodeMetrics{}
+	for _, metric := range []string{promCpuUsageInstant, promMemUsageInstant} {
+		queryStr := p.renderQuery(metric, nodeName, period)
+		klog.V(4).Infof("Range query %s by %s", p.address, queryStr)
+		res, err := p.queryRange(ctx, queryStr, period, step)
+		if err != nil {
+			return nil, err
+		}
+		values, ok := firstSeriesValues(res)
+		if !ok {
+			klog.Warningf("Warning range querying %s: no data found for %s", p.address, queryStr)
+			continue
+		}
+		stats := summarize(values)
+		switch metric {
+		case promCpuUsageInstant:
+			nodeMetrics.CpuP50, nodeMetrics.CpuP95, nodeMetrics.CpuP99 = stats.p50, stats.p95, stats.p99
+			nodeMetrics.CpuMax, nodeMetrics.CpuTrend = stats.max, stats.trend
+		case promMemUsageInstant:
+			nodeMetrics.MemP50, nodeMetrics.MemP95, nodeMetrics.MemP99 = stats.p50, stats.p95, stats.p99
+			nodeMetrics.MemMax, nodeMetrics.MemTrend = stats.max, stats.trend
+		}
+	}
+	return nodeMetrics, nil
+}
+
+type sampleStats struct {
+	p50, p95, p99 float64
+	max           float64
+	trend         float64
+}
+
+// summarize computes percentile/max/trend statistics over a series of
+// samples in chronological order.
+func summarize(values []float64) sampleStats {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	return sampleStats{
+		p50:   percentile(sorted, 50),
+		p95:   percentile(sorted, 95),
+		p99:   percentile(sorted, 99),
+		max:   sorted[len(sorted)-1],
+		trend: slope(values),
+	}
+}
+
+// percentile returns the p-th percentile of sorted (ascending) values: the
+// element at index p/100*(len-1), rounded to the nearest index.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	rank := int(p/100*float64(len(sorted)-1) + 0.5)
+	return sorted[rank]
+}
+
+// slope fits a line to values indexed by their chronological position and
+// returns its slope via simple linear regression, i.e. how fast usage is
+// trending up (positive) or down (negative) per sample.
+func slope(values []float64) float64 {
+	n := float64(len(values))
+	if n < 2 {
+		return 0
+	}
+
+	var sumX, sumY, sumXY, sumXX float64
+	for i, y := range values {
+		x := float64(i)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denominator
+}
@@ -0,0 +1,91 @@
+/*
+ Copyright 2023 The Volcano Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package source
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPercentileSingleValue(t *testing.T) {
+	if got := percentile([]float64{42}, 99); got != 42 {
+		t.Fatalf("got %v, want 42", got)
+	}
+}
+
+func TestPercentileNearestRank(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	cases := []struct {
+		p    float64
+		want float64
+	}{
+		{50, 6},
+		{95, 10},
+		{99, 10},
+		{0, 1},
+	}
+	for _, c := range cases {
+		if got := percentile(sorted, c.p); got != c.want {
+			t.Errorf("percentile(%v, %v) = %v, want %v", sorted, c.p, got, c.want)
+		}
+	}
+}
+
+func TestSlopeConstantValues(t *testing.T) {
+	if got := slope([]float64{5, 5, 5, 5}); got != 0 {
+		t.Fatalf("got %v, want 0 for a constant series", got)
+	}
+}
+
+func TestSlopeSingleValue(t *testing.T) {
+	if got := slope([]float64{5}); got != 0 {
+		t.Fatalf("got %v, want 0 for fewer than 2 points", got)
+	}
+}
+
+func TestSlopeIncreasingTrend(t *testing.T) {
+	got := slope([]float64{1, 2, 3, 4, 5})
+	if math.Abs(got-1) > 1e-9 {
+		t.Fatalf("got %v, want 1 for a unit-slope line", got)
+	}
+}
+
+func TestSlopeDecreasingTrend(t *testing.T) {
+	got := slope([]float64{5, 4, 3, 2, 1})
+	if math.Abs(got-(-1)) > 1e-9 {
+		t.Fatalf("got %v, want -1 for a unit-downward-slope line", got)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	stats := summarize([]float64{1, 2, 3, 4, 5})
+	if stats.max != 5 {
+		t.Errorf("max = %v, want 5", stats.max)
+	}
+	if stats.p50 != 3 {
+		t.Errorf("p50 = %v, want 3", stats.p50)
+	}
+	if math.Abs(stats.trend-1) > 1e-9 {
+		t.Errorf("trend = %v, want 1", stats.trend)
+	}
+}
+
+func TestFirstSeriesValues(t *testing.T) {
+	if _, ok := firstSeriesValues(nil); ok {
+		t.Fatalf("expected no values for a non-Matrix result")
+	}
+}